@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v2"
+)
+
+// Principal is the authenticated identity attached to a request, surfaced
+// to Lua scripts as the "request.user" table.
+type Principal struct {
+	Name   string                 `json:"name"`
+	Roles  []string               `json:"roles"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// HasRole reports whether p was granted role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated is returned by an authProvider when its credential
+// scheme isn't present in the request at all (as opposed to being present
+// but invalid), so the auth middleware can fall through to the next
+// configured provider.
+var ErrUnauthenticated = errors.New("no credentials presented")
+
+// authProvider authenticates one request into a Principal.
+type authProvider interface {
+	Authenticate(c *gin.Context) (*Principal, error)
+}
+
+// newAuthProviders builds the configured provider chain in order. An empty
+// or "none" provider list disables auth entirely (the zero-value behavior
+// matches the server's pre-auth hardcoded-BasicAuth era).
+func newAuthProviders(cfg AuthConfig) ([]authProvider, error) {
+	var providers []authProvider
+
+	for _, name := range cfg.Providers {
+		switch name {
+		case "jwt":
+			if cfg.JWT.Secret == "" {
+				return nil, errors.New("auth: jwt provider configured without a secret")
+			}
+			providers = append(providers, &jwtProvider{secret: []byte(cfg.JWT.Secret)})
+		case "api_key":
+			keys, err := loadAPIKeys(cfg.APIKeys.File)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, &apiKeyProvider{keys: keys})
+		case "oidc":
+			providers = append(providers, &oidcProvider{
+				introspectionURL: cfg.OIDC.IntrospectionURL,
+				clientID:         cfg.OIDC.ClientID,
+				clientSecret:     cfg.OIDC.ClientSecret,
+			})
+		case "none", "":
+			// explicitly disabled
+		default:
+			return nil, errors.New("auth: unknown provider " + name)
+		}
+	}
+
+	return providers, nil
+}
+
+// authMiddleware tries each provider in order and attaches the first
+// successful Principal to the gin.Context under "principal". If providers
+// is empty, auth is disabled and requests pass through unauthenticated.
+func authMiddleware(providers []authProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(providers) == 0 {
+			c.Next()
+			return
+		}
+
+		var lastErr error
+		for _, p := range providers {
+			principal, err := p.Authenticate(c)
+			if err == nil {
+				c.Set("principal", principal)
+				c.Next()
+				return
+			}
+			if err != ErrUnauthenticated {
+				lastErr = err
+			}
+		}
+
+		if lastErr == nil {
+			lastErr = errors.New("no valid credentials presented")
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": lastErr.Error()})
+	}
+}
+
+// principalFromContext returns the authenticated Principal for c, or nil if
+// auth is disabled or the request is otherwise unauthenticated.
+func principalFromContext(c *gin.Context) *Principal {
+	v, ok := c.Get("principal")
+	if !ok {
+		return nil
+	}
+	p, _ := v.(*Principal)
+	return p
+}
+
+// requireRole aborts the request with 403 unless it carries a Principal with
+// role. Unlike authMiddleware, requireRole does not fall back to "pass
+// through" when auth is disabled (no providers configured): an admin route
+// guarded by this is never covered by the "no providers = wide open"
+// default.
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principalFromContext(c)
+		if principal == nil || !principal.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "requires the " + role + " role"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// principalToTable converts principal into the table exposed to scripts as
+// request.user: {name, roles, claims}. A nil principal (auth disabled, or
+// no credentials resolved) becomes an empty table.
+func principalToTable(L *lua.LState, principal *Principal) *lua.LTable {
+	if principal == nil {
+		return L.CreateTable(0, 0)
+	}
+
+	tbl := L.CreateTable(0, 3)
+	tbl.RawSetString("name", lua.LString(principal.Name))
+	tbl.RawSetString("roles", toLValue(L, rolesToInterfaceSlice(principal.Roles)))
+	tbl.RawSetString("claims", toLValue(L, principal.Claims))
+	return tbl
+}
+
+func rolesToInterfaceSlice(roles []string) []interface{} {
+	out := make([]interface{}, len(roles))
+	for i, r := range roles {
+		out[i] = r
+	}
+	return out
+}
+
+// scriptAllowed enforces filename's allow_roles ACL (see ScriptACL) against
+// principal. A script with no ACL entry, or an entry with no AllowRoles, is
+// open to any caller who made it past authMiddleware.
+func scriptAllowed(cfg Config, filename string, principal *Principal) bool {
+	acl, ok := cfg.Scripts[filename]
+	if !ok || len(acl.AllowRoles) == 0 {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	for _, role := range acl.AllowRoles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// jwtProvider authenticates HMAC-signed "Authorization: Bearer <token>" JWTs.
+type jwtProvider struct {
+	secret []byte
+}
+
+func (p *jwtProvider) Authenticate(c *gin.Context) (*Principal, error) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrUnauthenticated
+	}
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return p.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid bearer token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid bearer token claims")
+	}
+
+	name, _ := claims["sub"].(string)
+	var roles []string
+	if raw, ok := claims["roles"].([]interface{}); ok {
+		for _, r := range raw {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+
+	return &Principal{Name: name, Roles: roles, Claims: claims}, nil
+}
+
+// apiKeyProvider authenticates the "X-API-Key" header against a static set
+// of keys loaded from file.
+type apiKeyProvider struct {
+	keys map[string]Principal
+}
+
+func (p *apiKeyProvider) Authenticate(c *gin.Context) (*Principal, error) {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	principal, ok := p.keys[key]
+	if !ok {
+		return nil, errors.New("invalid api key")
+	}
+	return &principal, nil
+}
+
+// apiKeyFile is the on-disk shape of the API keys file: a map of
+// key -> {name, roles}.
+type apiKeyFile map[string]struct {
+	Name  string   `yaml:"name"`
+	Roles []string `yaml:"roles"`
+}
+
+func loadAPIKeys(path string) (map[string]Principal, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw apiKeyFile
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]Principal, len(raw))
+	for key, entry := range raw {
+		keys[key] = Principal{Name: entry.Name, Roles: entry.Roles}
+	}
+	return keys, nil
+}
+
+// oidcProvider authenticates "Authorization: Bearer <token>" via an OIDC
+// provider's token introspection endpoint (RFC 7662).
+type oidcProvider struct {
+	clientID         string
+	clientSecret     string
+	introspectionURL string
+}
+
+func (p *oidcProvider) Authenticate(c *gin.Context) (*Principal, error) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrUnauthenticated
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", p.introspectionURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Active   bool     `json:"active"`
+		Username string   `json:"username"`
+		Roles    []string `json:"roles"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Active {
+		return nil, errors.New("token inactive")
+	}
+
+	return &Principal{Name: result.Username, Roles: result.Roles}, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func contextWithAuthHeader(header string) *gin.Context {
+	req := httptest.NewRequest(http.MethodPost, "/runLuaFile/test.lua", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
+}
+
+func TestJWTProviderAcceptsValidHMACToken(t *testing.T) {
+	secret := []byte("test-secret")
+	p := &jwtProvider{secret: secret}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "alice",
+		"roles": []interface{}{"admin"},
+	})
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+
+	principal, err := p.Authenticate(contextWithAuthHeader("Bearer " + signed))
+	if err != nil {
+		t.Fatalf("expected a valid HMAC token to authenticate, got: %v", err)
+	}
+	if principal.Name != "alice" || !principal.HasRole("admin") {
+		t.Errorf("unexpected principal: %+v", principal)
+	}
+}
+
+func TestJWTProviderRejectsAlgConfusion(t *testing.T) {
+	// A token signed with RS256 by an attacker-controlled RSA key, crafted to
+	// see if the verifier can be tricked into treating p.secret as an HMAC
+	// key under RS256 (the classic alg-confusion attack). p.Authenticate
+	// must reject it outright rather than attempt HMAC verification.
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":   "mallory",
+		"roles": []interface{}{"admin"},
+	})
+	signed, err := token.SignedString(rsaKey)
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+
+	p := &jwtProvider{secret: []byte("test-secret")}
+	if _, err := p.Authenticate(contextWithAuthHeader("Bearer " + signed)); err == nil {
+		t.Error("expected an RS256 token to be rejected by an HMAC-only provider")
+	}
+}
+
+func TestJWTProviderRejectsWrongSecret(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"})
+	signed, err := token.SignedString([]byte("right-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+
+	p := &jwtProvider{secret: []byte("wrong-secret")}
+	if _, err := p.Authenticate(contextWithAuthHeader("Bearer " + signed)); err == nil {
+		t.Error("expected a token signed with a different secret to be rejected")
+	}
+}
+
+func TestJWTProviderPassesThroughWithoutBearerHeader(t *testing.T) {
+	p := &jwtProvider{secret: []byte("test-secret")}
+	if _, err := p.Authenticate(contextWithAuthHeader("")); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated with no Authorization header, got: %v", err)
+	}
+}
+
+func TestScriptAllowed(t *testing.T) {
+	cfg := Config{
+		Scripts: map[string]ScriptACL{
+			"restricted.lua": {AllowRoles: []string{"admin"}},
+		},
+	}
+
+	if !scriptAllowed(cfg, "open.lua", nil) {
+		t.Error("a script with no ACL entry should be open to any caller")
+	}
+	if scriptAllowed(cfg, "restricted.lua", nil) {
+		t.Error("a restricted script should not be allowed without a principal")
+	}
+	if scriptAllowed(cfg, "restricted.lua", &Principal{Name: "bob", Roles: []string{"user"}}) {
+		t.Error("a principal missing the required role should not be allowed")
+	}
+	if !scriptAllowed(cfg, "restricted.lua", &Principal{Name: "alice", Roles: []string{"admin"}}) {
+		t.Error("a principal holding an allow_roles role should be allowed")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LuaConfig controls which parts of the Lua runtime are exposed to scripts.
+type LuaConfig struct {
+	// Modules is the set of stdlib and extension modules available to a
+	// script, both stdlib libs opened eagerly ("base", "table", "string",
+	// "math") and require()-able extensions preloaded on demand ("json",
+	// "http", "url"). Anything omitted is unreachable from the script,
+	// regardless of what else is enabled.
+	Modules []string `yaml:"modules"`
+}
+
+// ScriptConfig controls script execution limits.
+type ScriptConfig struct {
+	// DefaultTimeoutSeconds bounds how long a single script run may take
+	// before its context is cancelled. Overridable per-request via the
+	// "timeout" query param.
+	DefaultTimeoutSeconds int `yaml:"default_timeout_seconds"`
+}
+
+// JobStoreConfig selects and configures the JobStore backend.
+type JobStoreConfig struct {
+	// Backend is "memory" (default) or "badger".
+	Backend string `yaml:"backend"`
+	// TTLSeconds bounds how long a finished job's record is kept around.
+	TTLSeconds int `yaml:"ttl_seconds"`
+	// MemoryCapacity caps the number of jobs the memory backend holds at
+	// once, evicting the oldest once exceeded. Unused by the badger backend.
+	MemoryCapacity int `yaml:"memory_capacity"`
+	// BadgerDir is the on-disk directory for the badger backend.
+	BadgerDir string `yaml:"badger_dir"`
+}
+
+// AuthConfig selects and configures the authentication providers tried, in
+// order, for each request.
+type AuthConfig struct {
+	// Providers is tried in order until one authenticates the request.
+	// Supported values: "jwt", "api_key", "oidc". Empty disables auth.
+	Providers []string `yaml:"providers"`
+
+	JWT struct {
+		// Secret is the HMAC key used to verify bearer JWTs.
+		Secret string `yaml:"secret"`
+	} `yaml:"jwt"`
+
+	APIKeys struct {
+		// File is a YAML file mapping API key -> {name, roles}.
+		File string `yaml:"file"`
+	} `yaml:"api_keys"`
+
+	OIDC struct {
+		IntrospectionURL string `yaml:"introspection_url"`
+		ClientID         string `yaml:"client_id"`
+		ClientSecret     string `yaml:"client_secret"`
+	} `yaml:"oidc"`
+}
+
+// ScriptACL restricts a script to principals holding one of AllowRoles. An
+// empty AllowRoles allows any authenticated (or, if auth is disabled, any)
+// caller.
+type ScriptACL struct {
+	AllowRoles []string `yaml:"allow_roles"`
+}
+
+// Config is the top-level server configuration, loaded from a YAML file.
+type Config struct {
+	Lua      LuaConfig            `yaml:"lua"`
+	Script   ScriptConfig         `yaml:"script"`
+	JobStore JobStoreConfig       `yaml:"job_store"`
+	Auth     AuthConfig           `yaml:"auth"`
+	Scripts  map[string]ScriptACL `yaml:"scripts"`
+}
+
+// defaultConfig is used when no config file is present or a setting is omitted.
+func defaultConfig() Config {
+	return Config{
+		Lua: LuaConfig{
+			Modules: []string{"base", "table", "string", "math"},
+		},
+		Script: ScriptConfig{
+			DefaultTimeoutSeconds: 30,
+		},
+		JobStore: JobStoreConfig{
+			Backend:        "memory",
+			TTLSeconds:     3600,
+			MemoryCapacity: 10000,
+			BadgerDir:      "./data/jobs",
+		},
+	}
+}
+
+// DefaultTimeout returns the configured default script timeout as a Duration.
+func (c Config) DefaultTimeout() time.Duration {
+	return time.Duration(c.Script.DefaultTimeoutSeconds) * time.Second
+}
+
+// loadConfig reads a YAML config file at path, falling back to defaultConfig
+// for any field left unset. A missing file is not an error.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if len(cfg.Lua.Modules) == 0 {
+		cfg.Lua.Modules = defaultConfig().Lua.Modules
+	}
+	if cfg.Script.DefaultTimeoutSeconds == 0 {
+		cfg.Script.DefaultTimeoutSeconds = defaultConfig().Script.DefaultTimeoutSeconds
+	}
+	if cfg.JobStore.Backend == "" {
+		cfg.JobStore.Backend = defaultConfig().JobStore.Backend
+	}
+	if cfg.JobStore.TTLSeconds == 0 {
+		cfg.JobStore.TTLSeconds = defaultConfig().JobStore.TTLSeconds
+	}
+	if cfg.JobStore.MemoryCapacity == 0 {
+		cfg.JobStore.MemoryCapacity = defaultConfig().JobStore.MemoryCapacity
+	}
+	if cfg.JobStore.BadgerDir == "" {
+		cfg.JobStore.BadgerDir = defaultConfig().JobStore.BadgerDir
+	}
+
+	return cfg, nil
+}
+
+// TTL returns the configured job TTL as a Duration.
+func (c Config) TTL() time.Duration {
+	return time.Duration(c.JobStore.TTLSeconds) * time.Second
+}
@@ -0,0 +1,66 @@
+package main
+
+import "sync"
+
+// JobEvent is one item streamed to GET /jobs/:id/events: either a status
+// transition or a log.info(...) message emitted by the running script.
+type JobEvent struct {
+	Type    string    `json:"type"` // "status" or "log"
+	Status  JobStatus `json:"status,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// jobEventBus fans out JobEvents to any number of subscribers per job id.
+// It is purely in-process: events are not persisted, so a subscriber that
+// connects after a script finishes only sees the terminal "status" event
+// published right before the job is removed from the bus.
+type jobEventBus struct {
+	mu   sync.Mutex
+	subs map[string][]chan JobEvent
+}
+
+func newJobEventBus() *jobEventBus {
+	return &jobEventBus{subs: make(map[string][]chan JobEvent)}
+}
+
+// Subscribe registers a new listener for id's events. The caller must call
+// the returned unsubscribe func once done reading.
+func (b *jobEventBus) Subscribe(id string) (<-chan JobEvent, func()) {
+	ch := make(chan JobEvent, 16)
+
+	b.mu.Lock()
+	b.subs[id] = append(b.subs[id], ch)
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		chans := b.subs[id]
+		for i, c := range chans {
+			if c == ch {
+				b.subs[id] = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers ev to every current subscriber of id. Slow subscribers
+// are dropped rather than allowed to block the publishing script.
+func (b *jobEventBus) Publish(id string, ev JobEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subs[id] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// appEvents is the process-wide bus used by runLuaScript's log module and
+// the GET /jobs/:id/events handler.
+var appEvents = newJobEventBus()
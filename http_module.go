@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// sharedHTTPClient is reused across every script invocation so requests
+// benefit from connection pooling instead of allocating a fresh client
+// (and transport) per call.
+var sharedHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// httpLoader preloads the "http" module: http.request(method, url, opts) plus
+// the http.get/post/put/delete/patch convenience wrappers. opts is a table
+// supporting headers, query, body, form and timeout fields.
+func httpLoader(L *lua.LState) int {
+	mod := L.NewTable()
+
+	L.SetFuncs(mod, map[string]lua.LGFunction{
+		"request": httpRequest,
+		"get":     httpMethod("GET"),
+		"post":    httpMethod("POST"),
+		"put":     httpMethod("PUT"),
+		"delete":  httpMethod("DELETE"),
+		"patch":   httpMethod("PATCH"),
+	})
+
+	L.Push(mod)
+	return 1
+}
+
+// httpMethod returns an L.GFunction that calls doRequest with method fixed,
+// so e.g. http.get(url, opts) is request("GET", url, opts).
+func httpMethod(method string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		rawURL := L.CheckString(1)
+		opts := L.OptTable(2, L.NewTable())
+		return doRequest(L, method, rawURL, opts)
+	}
+}
+
+func httpRequest(L *lua.LState) int {
+	method := strings.ToUpper(L.CheckString(1))
+	rawURL := L.CheckString(2)
+	opts := L.OptTable(3, L.NewTable())
+	return doRequest(L, method, rawURL, opts)
+}
+
+func doRequest(L *lua.LState, method, rawURL string, opts *lua.LTable) int {
+	reqURL, err := url.Parse(rawURL)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("invalid url: " + err.Error()))
+		return 2
+	}
+
+	if query, ok := opts.RawGetString("query").(*lua.LTable); ok {
+		q := reqURL.Query()
+		query.ForEach(func(k, v lua.LValue) {
+			q.Set(k.String(), v.String())
+		})
+		reqURL.RawQuery = q.Encode()
+	}
+
+	body, contentType, err := requestBody(opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	ctx := L.Context()
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), body)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("failed to create request: " + err.Error()))
+		return 2
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := opts.RawGetString("headers").(*lua.LTable); ok {
+		headers.ForEach(func(k, v lua.LValue) {
+			req.Header.Set(k.String(), v.String())
+		})
+	}
+
+	client := sharedHTTPClient
+	if timeout, ok := opts.RawGetString("timeout").(lua.LNumber); ok {
+		c := *sharedHTTPClient
+		c.Timeout = time.Duration(float64(timeout) * float64(time.Second))
+		client = &c
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("request failed: " + err.Error()))
+		return 2
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("failed to read response body: " + err.Error()))
+		return 2
+	}
+
+	L.Push(responseToTable(L, resp, respBody))
+	return 1
+}
+
+// requestBody builds the outgoing request body from opts.body (JSON-encoded
+// unless opts.form is set, in which case it's URL-form-encoded) and returns
+// its matching Content-Type.
+func requestBody(opts *lua.LTable) (*strings.Reader, string, error) {
+	if form, ok := opts.RawGetString("form").(*lua.LTable); ok {
+		values := url.Values{}
+		form.ForEach(func(k, v lua.LValue) {
+			values.Set(k.String(), v.String())
+		})
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	body := opts.RawGetString("body")
+	if body == lua.LNil {
+		return strings.NewReader(""), "", nil
+	}
+
+	payload, err := json.Marshal(fromLValue(body))
+	if err != nil {
+		return nil, "", err
+	}
+	return strings.NewReader(string(payload)), "application/json", nil
+}
+
+// responseToTable converts an *http.Response into the {status_code, headers,
+// body, cookies} table handed back to Lua.
+func responseToTable(L *lua.LState, resp *http.Response, body []byte) *lua.LTable {
+	tbl := L.CreateTable(0, 4)
+	tbl.RawSetString("status_code", lua.LNumber(resp.StatusCode))
+	tbl.RawSetString("body", lua.LString(string(body)))
+
+	headers := L.CreateTable(0, len(resp.Header))
+	for key := range resp.Header {
+		headers.RawSetString(key, lua.LString(resp.Header.Get(key)))
+	}
+	tbl.RawSetString("headers", headers)
+
+	cookies := L.CreateTable(len(resp.Cookies()), 0)
+	for i, cookie := range resp.Cookies() {
+		c := L.CreateTable(0, 2)
+		c.RawSetString("name", lua.LString(cookie.Name))
+		c.RawSetString("value", lua.LString(cookie.Value))
+		cookies.RawSetInt(i+1, c)
+	}
+	tbl.RawSetString("cookies", cookies)
+
+	return tbl
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// JobStatus is the lifecycle state of a script execution.
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusFinished  JobStatus = "finished"
+	JobStatusError     JobStatus = "error"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// Job is a persisted record of one script execution, as returned by
+// GET /status/:id and GET /jobs.
+type Job struct {
+	ID         string                 `json:"id"`
+	Filename   string                 `json:"filename"`
+	Payload    map[string]interface{} `json:"payload"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at,omitempty"`
+	Result     string                 `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Status     JobStatus              `json:"status"`
+}
+
+// ErrJobNotFound is returned by JobStore.Get when no job with the given id
+// exists (or it has expired).
+var ErrJobNotFound = errors.New("job not found")
+
+// JobStore persists Job records across the lifetime of a script run (and,
+// for persistent implementations, across process restarts).
+type JobStore interface {
+	Put(job *Job) error
+	Get(id string) (*Job, error)
+	// List returns up to limit jobs older than cursor (a job id), ordered
+	// newest-first, plus the cursor to pass to the next call. nextCursor
+	// is "" once the end of the list has been reached.
+	List(limit int, cursor string) (jobs []*Job, nextCursor string, err error)
+}
+
+// memoryJobStore is an in-memory JobStore with LRU eviction bounded by both
+// a max size and a per-entry TTL. It is the default JobStore when no
+// persistent backend is configured.
+type memoryJobStore struct {
+	mu    sync.RWMutex
+	ttl   time.Duration
+	cap   int
+	jobs  map[string]*list.Element // id -> node in order
+	order *list.List               // front = most recently put
+}
+
+type jobEntry struct {
+	id        string
+	job       *Job
+	expiresAt time.Time
+}
+
+// newMemoryJobStore creates an in-memory JobStore that evicts entries older
+// than ttl and never holds more than capacity entries.
+func newMemoryJobStore(ttl time.Duration, capacity int) *memoryJobStore {
+	return &memoryJobStore{
+		ttl:   ttl,
+		cap:   capacity,
+		jobs:  make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (s *memoryJobStore) Put(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Store our own copy so later mutations the caller makes to job (e.g. the
+	// runLuaFileAsync goroutine updating its local *Job before the terminal
+	// Put) can never race with a concurrent Get/List reading the stored copy.
+	stored := cloneJob(job)
+	entry := &jobEntry{id: stored.ID, job: stored, expiresAt: time.Now().Add(s.ttl)}
+
+	if el, ok := s.jobs[job.ID]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+	} else {
+		s.jobs[job.ID] = s.order.PushFront(entry)
+	}
+
+	s.evictLocked()
+	return nil
+}
+
+func (s *memoryJobStore) Get(id string) (*Job, error) {
+	s.mu.RLock()
+	el, ok := s.jobs[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	entry := el.Value.(*jobEntry)
+	if time.Now().After(entry.expiresAt) {
+		return nil, ErrJobNotFound
+	}
+	return cloneJob(entry.job), nil
+}
+
+func (s *memoryJobStore) List(limit int, cursor string) ([]*Job, string, error) {
+	if limit <= 0 {
+		return nil, "", nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	el := s.order.Front()
+	if cursor != "" {
+		start, ok := s.jobs[cursor]
+		if !ok {
+			return nil, "", ErrJobNotFound
+		}
+		el = start.Next()
+	}
+
+	jobs := make([]*Job, 0, limit)
+	for ; el != nil && len(jobs) < limit; el = el.Next() {
+		entry := el.Value.(*jobEntry)
+		if time.Now().After(entry.expiresAt) {
+			continue
+		}
+		jobs = append(jobs, cloneJob(entry.job))
+	}
+
+	nextCursor := ""
+	if el != nil && len(jobs) > 0 {
+		nextCursor = jobs[len(jobs)-1].ID
+	}
+	return jobs, nextCursor, nil
+}
+
+// cloneJob returns a shallow copy of job. Job's fields are all value types
+// except Payload, which is never mutated after a job is created, so a
+// shallow copy is enough to let the store and its callers each hold an
+// independent *Job safe to read or mutate without synchronization.
+func cloneJob(job *Job) *Job {
+	clone := *job
+	return &clone
+}
+
+// evictLocked removes expired entries and, if still over capacity, the
+// oldest entries until the store fits. Callers must hold s.mu.
+func (s *memoryJobStore) evictLocked() {
+	now := time.Now()
+	for el := s.order.Back(); el != nil; {
+		entry := el.Value.(*jobEntry)
+		prev := el.Prev()
+		if now.After(entry.expiresAt) {
+			s.order.Remove(el)
+			delete(s.jobs, entry.id)
+		}
+		el = prev
+	}
+
+	for s.order.Len() > s.cap {
+		el := s.order.Back()
+		entry := el.Value.(*jobEntry)
+		s.order.Remove(el)
+		delete(s.jobs, entry.id)
+	}
+}
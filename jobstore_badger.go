@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+// badgerJobStore is a JobStore backed by an embedded BadgerDB, so jobs
+// survive process restarts. Keys are "job:<id>"; TTL is enforced by
+// Badger's own entry expiry rather than manual eviction.
+type badgerJobStore struct {
+	db  *badger.DB
+	ttl time.Duration
+}
+
+const badgerJobKeyPrefix = "job:"
+
+// newBadgerJobStore opens (or creates) a BadgerDB at dir, keeping jobs for
+// ttl before they expire.
+func newBadgerJobStore(dir string, ttl time.Duration) (*badgerJobStore, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return nil, err
+	}
+	return &badgerJobStore{db: db, ttl: ttl}, nil
+}
+
+func (s *badgerJobStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *badgerJobStore) Put(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		entry := badger.NewEntry([]byte(badgerJobKeyPrefix+job.ID), data).WithTTL(s.ttl)
+		return txn.SetEntry(entry)
+	})
+}
+
+func (s *badgerJobStore) Get(id string) (*Job, error) {
+	var job Job
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(badgerJobKeyPrefix + id))
+		if err == badger.ErrKeyNotFound {
+			return ErrJobNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &job)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List iterates jobs in reverse key order (newest id last written sorts
+// last lexically for UUIDs, so we collect everything matching the prefix
+// and rely on StartedAt for ordering).
+func (s *badgerJobStore) List(limit int, cursor string) ([]*Job, string, error) {
+	if limit <= 0 {
+		return nil, "", nil
+	}
+
+	var all []*Job
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(badgerJobKeyPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(opts.Prefix); it.Next() {
+			var job Job
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &job)
+			}); err != nil {
+				return err
+			}
+			all = append(all, &job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sortJobsNewestFirst(all)
+
+	start := 0
+	if cursor != "" {
+		for i, job := range all {
+			if job.ID == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := start + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	page := all[start:end]
+
+	nextCursor := ""
+	if end < len(all) && len(page) > 0 {
+		nextCursor = page[len(page)-1].ID
+	}
+	return page, nextCursor, nil
+}
+
+func sortJobsNewestFirst(jobs []*Job) {
+	for i := 1; i < len(jobs); i++ {
+		for j := i; j > 0 && jobs[j].StartedAt.After(jobs[j-1].StartedAt); j-- {
+			jobs[j], jobs[j-1] = jobs[j-1], jobs[j]
+		}
+	}
+}
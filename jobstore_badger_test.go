@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBadgerJobStore(t *testing.T) *badgerJobStore {
+	t.Helper()
+	s, err := newBadgerJobStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("newBadgerJobStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBadgerJobStoreListEmptyDoesNotPanic(t *testing.T) {
+	s := newTestBadgerJobStore(t)
+
+	jobs, cursor, err := s.List(0, "")
+	if err != nil {
+		t.Fatalf("List with limit=0 on an empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+
+	jobs, cursor, err = s.List(-1, "")
+	if err != nil {
+		t.Fatalf("List with limit=-1 on an empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+}
+
+func TestBadgerJobStoreListNonEmptyZeroLimitDoesNotPanic(t *testing.T) {
+	s := newTestBadgerJobStore(t)
+	if err := s.Put(&Job{ID: "1", Status: JobStatusRunning, StartedAt: time.Now()}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	jobs, cursor, err := s.List(0, "")
+	if err != nil {
+		t.Fatalf("List with limit=0 on a non-empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+
+	jobs, cursor, err = s.List(-1, "")
+	if err != nil {
+		t.Fatalf("List with limit=-1 on a non-empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+}
+
+func TestBadgerJobStoreListPaginates(t *testing.T) {
+	s := newTestBadgerJobStore(t)
+
+	base := time.Now()
+	for i, id := range []string{"1", "2", "3"} {
+		job := &Job{ID: id, Status: JobStatusFinished, StartedAt: base.Add(time.Duration(i) * time.Second)}
+		if err := s.Put(job); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	jobs, cursor, err := s.List(2, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs on first page, got %d", len(jobs))
+	}
+	if cursor == "" {
+		t.Fatal("expected a cursor since more jobs remain")
+	}
+
+	jobs, cursor, err = s.List(2, cursor)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job on second page, got %d", len(jobs))
+	}
+	if cursor != "" {
+		t.Errorf("expected no cursor once the end of the list is reached, got %q", cursor)
+	}
+}
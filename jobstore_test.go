@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryJobStoreGetReturnsACopy(t *testing.T) {
+	s := newMemoryJobStore(time.Hour, 10)
+
+	job := &Job{ID: "1", Status: JobStatusRunning}
+	if err := s.Put(job); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := s.Get("1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got.Status = JobStatusFinished
+
+	again, err := s.Get("1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again.Status != JobStatusRunning {
+		t.Errorf("mutating a Get result leaked into the store: got status %q, want %q", again.Status, JobStatusRunning)
+	}
+
+	// Mutating the original job passed to Put must not affect the stored
+	// copy either.
+	job.Status = JobStatusError
+	again, err = s.Get("1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again.Status != JobStatusRunning {
+		t.Errorf("mutating the job passed to Put leaked into the store: got status %q, want %q", again.Status, JobStatusRunning)
+	}
+}
+
+func TestMemoryJobStoreListEmptyDoesNotPanic(t *testing.T) {
+	s := newMemoryJobStore(time.Hour, 10)
+
+	jobs, cursor, err := s.List(0, "")
+	if err != nil {
+		t.Fatalf("List with limit=0 on an empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+
+	jobs, cursor, err = s.List(-1, "")
+	if err != nil {
+		t.Fatalf("List with limit=-1 on an empty store: %v", err)
+	}
+	if len(jobs) != 0 || cursor != "" {
+		t.Errorf("expected no jobs and no cursor, got %d jobs, cursor %q", len(jobs), cursor)
+	}
+}
+
+func TestMemoryJobStoreListReturnsCopies(t *testing.T) {
+	s := newMemoryJobStore(time.Hour, 10)
+	if err := s.Put(&Job{ID: "1", Status: JobStatusRunning}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	jobs, _, err := s.List(10, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	jobs[0].Status = JobStatusFinished
+
+	again, err := s.Get("1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again.Status != JobStatusRunning {
+		t.Errorf("mutating a List result leaked into the store: got status %q, want %q", again.Status, JobStatusRunning)
+	}
+}
+
+// TestMemoryJobStoreConcurrentPutAndGet exercises the pattern the
+// runLuaFileAsync handler uses: one goroutine repeatedly Put-ing updates to a
+// job while others concurrently Get it. Run with -race to confirm no data
+// race between them.
+func TestMemoryJobStoreConcurrentPutAndGet(t *testing.T) {
+	s := newMemoryJobStore(time.Hour, 10)
+	if err := s.Put(&Job{ID: "1", Status: JobStatusRunning}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				s.Put(&Job{ID: "1", Status: JobStatusFinished, Result: "ok"})
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if _, err := s.Get("1"); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if _, _, err := s.List(10, ""); err != nil {
+			t.Fatalf("List: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// logLoader preloads the "log" module for a single script run: log.info(msg)
+// both writes to the server log and, if jobID is non-empty, publishes a
+// JobEvent so SSE subscribers on GET /jobs/:id/events see it live.
+func logLoader(jobID string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mod := L.NewTable()
+		L.SetFuncs(mod, map[string]lua.LGFunction{
+			"info": logInfo(jobID),
+		})
+		L.Push(mod)
+		return 1
+	}
+}
+
+func logInfo(jobID string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		msg := L.CheckString(1)
+
+		log.Printf("[script %s] %s", jobID, msg)
+		if jobID != "" {
+			appEvents.Publish(jobID, JobEvent{Type: "log", Message: msg})
+		}
+		return 0
+	}
+}
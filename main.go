@@ -1,45 +1,55 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/contrib/static"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/yuin/gopher-lua"
 	"golang.org/x/time/rate"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
 
-type ScriptStatus struct {
-	Finished bool
-	Result   string
-	Error    error
-}
+// appJobStore persists Job records across runLuaFileAsync invocations; see
+// jobstore.go. It's selected in main() based on appConfig.JobStore.Backend.
+var appJobStore JobStore = newMemoryJobStore(defaultConfig().TTL(), defaultConfig().JobStore.MemoryCapacity)
+
+// runningCancels holds the cancel func for every job currently executing,
+// keyed by job id, so DELETE /status/:id can abort it. JobStore itself only
+// tracks persisted state, not live Go values like a context.CancelFunc.
+var runningCancels = struct {
+	sync.Mutex
+	m map[string]context.CancelFunc
+}{m: make(map[string]context.CancelFunc)}
 
-var scriptStatuses = make(map[string]*ScriptStatus)
+// appScriptCache compiles and hot-reloads .lua scripts; see scriptcache.go.
+// Initialized in main() once -scripts-dir is known.
+var appScriptCache *ScriptCache
 
-// Cache for Lua scripts
-var scriptCache = make(map[string]string)
-var scriptCacheMutex = &sync.RWMutex{}
+// appConfig holds the server configuration loaded at startup in main().
+var appConfig = defaultConfig()
 
-// A map of rate limiters for each IP.
+// A map of rate limiters, one per visitor. The key is the authenticated
+// principal's name when auth is enabled, falling back to the client IP.
 var visitors = make(map[string]*rate.Limiter)
 var mtx = sync.Mutex{}
 
-// Create a new rate limiter and add it to the visitors map, using the
-// IP address as the key.
-func addVisitor(ip string) *rate.Limiter {
+// Create a new rate limiter and add it to the visitors map, using key
+// (a principal name or IP address) as the map key.
+func addVisitor(key string) *rate.Limiter {
 	limiter := rate.NewLimiter(1, 3)
 	mtx.Lock()
 	// Include the current time when creating a new visitor.
-	visitors[ip] = limiter
+	visitors[key] = limiter
 	mtx.Unlock()
 	return limiter
 }
@@ -47,155 +57,57 @@ func addVisitor(ip string) *rate.Limiter {
 // Retrieve and return the rate limiter for the current visitor if it
 // already exists. Otherwise call the addVisitor function to add a
 // new entry to the map.
-func getVisitor(ip string) *rate.Limiter {
+func getVisitor(key string) *rate.Limiter {
 	mtx.Lock()
-	limiter, exists := visitors[ip]
+	limiter, exists := visitors[key]
 	mtx.Unlock()
 	if !exists {
-		return addVisitor(ip)
+		return addVisitor(key)
 	}
 	return limiter
 }
 
-// Helper function to convert map to Lua table
-func mapToTable(L *lua.LState, m map[string]interface{}) *lua.LTable {
-	tbl := L.CreateTable(0, len(m))
-	for k, v := range m {
-		L.SetTable(tbl, lua.LString(k), lua.LString(v.(string))) // modify here if v isn't string
+// visitorKey returns the rate-limiting key for c: the authenticated
+// principal's name if present, otherwise the client IP.
+func visitorKey(c *gin.Context) string {
+	if principal := principalFromContext(c); principal != nil {
+		return "user:" + principal.Name
 	}
-	return tbl
+	return "ip:" + c.ClientIP()
 }
 
-func runLuaScript(filename string, jsonData map[string]interface{}) (string, error) {
-	// Check if the script is in cache
-	scriptCacheMutex.RLock()
-	content, ok := scriptCache[filename]
-	scriptCacheMutex.RUnlock()
-
-	// If not in cache, read the file
-	if !ok {
-		var err error
-		bytes, err := ioutil.ReadFile(filename)
-		if err != nil {
-			return "", err
+// requestTimeout resolves the script timeout for a request: the "timeout"
+// query param (in seconds) if present and valid, otherwise the configured
+// default.
+func requestTimeout(c *gin.Context) time.Duration {
+	if raw := c.Query("timeout"); raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
 		}
+	}
+	return appConfig.DefaultTimeout()
+}
 
-		// Convert to string and store the script in cache
-		content = string(bytes)
-		scriptCacheMutex.Lock()
-		scriptCache[filename] = content
-		scriptCacheMutex.Unlock()
+// runLuaScript runs filename with jsonData bound as the "payload" global.
+// jobID is the id used to publish log.info(...) messages to
+// GET /jobs/:id/events; pass "" for the synchronous /runLuaFile endpoint,
+// which has no such subscribers. principal is exposed to the script as
+// request.user; it may be nil if auth is disabled or didn't resolve one.
+func runLuaScript(ctx context.Context, jobID string, filename string, jsonData map[string]interface{}, principal *Principal) (string, error) {
+	script, err := appScriptCache.Load(filename)
+	if err != nil {
+		return "", err
 	}
 
-	// New lua state
-	L := lua.NewState()
+	// New lua state, sandboxed to the modules enabled in appConfig.
+	L := newSandboxedState(appConfig.Lua)
 	defer L.Close()
+	L.SetContext(ctx)
 
-	// Register httpPost Go function that can be called from Lua
-	L.SetGlobal("httpPost", L.NewFunction(func(L *lua.LState) int {
-		// Get arguments from Lua
-		url := L.CheckString(1)
-		body := L.CheckTable(2)
-
-		// Convert lua table to map
-		var bodyMap map[string]interface{}
-		body.ForEach(func(k lua.LValue, v lua.LValue) {
-			bodyMap[k.String()] = v.String()
-		})
-
-		// Convert map to json
-		bodyJson, err := json.Marshal(bodyMap)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to convert body to JSON: " + err.Error()))
-			return 2
-		}
-
-		// Create a new http client
-		client := &http.Client{}
-
-		// Create the request
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyJson))
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to create request: " + err.Error()))
-			return 2
-		}
-
-		// Send the request
-		resp, err := client.Do(req)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to send request: " + err.Error()))
-			return 2
-		}
-		defer resp.Body.Close()
-
-		// Read the response
-		responseData, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to read response: " + err.Error()))
-			return 2
-		}
-
-		// Return response to Lua
-		L.Push(lua.LString(string(responseData)))
-		return 1 // Number of return values
-	}))
-
-	// Create and register functions for Lua scripts to use.
-	L.SetGlobal("setHeader", L.NewFunction(func(L *lua.LState) int {
-		// Create new header
-		key := L.CheckString(1)
-		value := L.CheckString(2)
-
-		// Store header in Lua's global table
-		headers := L.GetGlobal("headers").(*lua.LTable)
-		headers.RawSetString(key, lua.LString(value))
-		return 0 // Number of return values
-	}))
-
-	L.SetGlobal("httpGet", L.NewFunction(func(L *lua.LState) int {
-		url := L.CheckString(1)
-		client := &http.Client{}
-
-		// Retrieve headers from Lua's global table
-		headers := L.GetGlobal("headers").(*lua.LTable)
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to create request: " + err.Error()))
-			return 2 // Number of return values
-		}
-
-		// Set headers in request
-		headers.ForEach(func(key lua.LValue, value lua.LValue) {
-			req.Header.Set(key.String(), value.String())
-		})
-
-		resp, err := client.Do(req)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to do request: " + err.Error()))
-			return 2 // Number of return values
-		}
-		defer resp.Body.Close()
-
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			L.Push(lua.LNil)
-			L.Push(lua.LString("Failed to read response body: " + err.Error()))
-			return 2 // Number of return values
-		}
-
-		L.Push(lua.LString(string(bodyBytes)))
-		return 1 // Number of return values
-	}))
-
-	// Initialize headers table
-	L.SetGlobal("headers", L.NewTable())
+	// http.get/post/put/delete/patch/request are available to scripts via
+	// the preloaded "http" module (require("http")); see http_module.go.
+	// log.info(...) streams to GET /jobs/:id/events for async runs.
+	L.PreloadModule("log", logLoader(jobID))
 
 	// Register custom Go function that can be called from Lua
 	L.SetGlobal("customGoFunction", L.NewFunction(func(L *lua.LState) int {
@@ -211,19 +123,68 @@ func runLuaScript(filename string, jsonData map[string]interface{}) (string, err
 	}))
 
 	// Convert map to Lua table and set as global variable
-	L.SetGlobal("payload", mapToTable(L, jsonData))
-
-	// Do the lua code
-	if err := L.DoString(content); err != nil {
+	L.SetGlobal("payload", toLValue(L, jsonData))
+
+	// Expose the authenticated principal (if any) as request.user.
+	request := L.CreateTable(0, 1)
+	request.RawSetString("user", principalToTable(L, principal))
+	L.SetGlobal("request", request)
+
+	// Push and call the pre-compiled script instead of re-parsing the source.
+	lfunc := L.NewFunctionFromProto(script.Proto)
+	L.Push(lfunc)
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", err
 	}
 
-	// Get the lua value
-	luaValue := L.Get(-1)
-	return luaValue.String(), nil
+	// Convert the returned lua value back to a Go value and marshal it to
+	// JSON so scripts can `return { ok = true, items = {...} }` and get a
+	// proper JSON document back, rather than Lua's tostring() formatting.
+	result, err := json.Marshal(fromLValue(L.Get(-1)))
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
 }
 
 func main() {
+	scriptsDir := flag.String("scripts-dir", ".", "directory containing .lua scripts")
+	flag.Parse()
+
+	cfg, err := loadConfig("config.yaml")
+	if err != nil {
+		log.Fatalf("failed to load config.yaml: %v", err)
+	}
+	appConfig = cfg
+
+	cache, err := newScriptCache(*scriptsDir)
+	if err != nil {
+		log.Fatalf("failed to watch scripts dir %s: %v", *scriptsDir, err)
+	}
+	appScriptCache = cache
+
+	switch cfg.JobStore.Backend {
+	case "badger":
+		store, err := newBadgerJobStore(cfg.JobStore.BadgerDir, cfg.TTL())
+		if err != nil {
+			log.Fatalf("failed to open badger job store at %s: %v", cfg.JobStore.BadgerDir, err)
+		}
+		appJobStore = store
+	default:
+		appJobStore = newMemoryJobStore(cfg.TTL(), cfg.JobStore.MemoryCapacity)
+	}
+
+	authProviders, err := newAuthProviders(cfg.Auth)
+	if err != nil {
+		log.Fatalf("failed to configure auth: %v", err)
+	}
+	if len(authProviders) == 0 {
+		log.Printf("WARNING: auth.providers is empty - auth is DISABLED, every endpoint is reachable without credentials (except /admin/reload and /admin/scripts, which always require the admin role)")
+	}
+
 	router := gin.Default()
 
 	router.Use(CORSMiddleware())
@@ -231,14 +192,11 @@ func main() {
 
 	// Middlewares
 	router.Use(static.Serve("/", static.LocalFile("./public", true)))
-	router.Use(gin.BasicAuth(gin.Accounts{
-		"user1": "password1",
-		"user2": "password2",
-	}))
+	router.Use(authMiddleware(authProviders))
 
-	// Rate limiter middleware
+	// Rate limiter middleware, keyed per-principal when auth is enabled.
 	router.Use(func(c *gin.Context) {
-		limiter := getVisitor(c.ClientIP())
+		limiter := getVisitor(visitorKey(c))
 		if !limiter.Allow() {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
 			return
@@ -246,29 +204,96 @@ func main() {
 		c.Next()
 	})
 
-	router.GET("/status/:id", func(c *gin.Context) {
+	router.DELETE("/status/:id", func(c *gin.Context) {
 		// Get id from the URL
 		id := c.Param("id")
 
-		status, ok := scriptStatuses[id]
+		runningCancels.Lock()
+		cancel, ok := runningCancels.m[id]
+		runningCancels.Unlock()
 		if !ok {
+			c.JSON(http.StatusConflict, gin.H{"error": "job not running"})
+			return
+		}
+
+		cancel()
+		c.JSON(http.StatusOK, gin.H{"cancelled": true})
+	})
+
+	router.GET("/status/:id", func(c *gin.Context) {
+		// Get id from the URL
+		id := c.Param("id")
+
+		job, err := appJobStore.Get(id)
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "script not found"})
 			return
 		}
 
-		// Note: You need to be careful about concurrently reading/writing to the script status.
-		// Consider adding a mutex lock or similar for thread safety.
-		if status.Finished {
+		if job.Status != JobStatusRunning {
 			c.JSON(http.StatusOK, gin.H{
 				"finished": true,
-				"result":   status.Result,
-				"error":    status.Error,
+				"result":   job.Result,
+				"error":    job.Error,
 			})
 		} else {
 			c.JSON(http.StatusOK, gin.H{"finished": false})
 		}
 	})
 
+	router.POST("/admin/reload", requireRole("admin"), func(c *gin.Context) {
+		appScriptCache.Reload()
+		c.JSON(http.StatusOK, gin.H{"reloaded": true})
+	})
+
+	router.GET("/admin/scripts", requireRole("admin"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"scripts": appScriptCache.List()})
+	})
+
+	router.GET("/jobs", func(c *gin.Context) {
+		limit := 50
+		if raw := c.Query("limit"); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+				limit = n
+			}
+		}
+
+		jobs, nextCursor, err := appJobStore.List(limit, c.Query("cursor"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs, "cursor": nextCursor})
+	})
+
+	router.GET("/jobs/:id/events", func(c *gin.Context) {
+		id := c.Param("id")
+		if _, err := appJobStore.Get(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+
+		events, unsubscribe := appEvents.Subscribe(id)
+		defer unsubscribe()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent(ev.Type, ev)
+				if ev.Type == "status" && ev.Status != JobStatusRunning {
+					return false
+				}
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	})
+
 	router.POST("/runLuaFileAsync/:filename", func(c *gin.Context) {
 		// Get filename from the URL
 		filename := c.Param("filename")
@@ -279,6 +304,12 @@ func main() {
 			return
 		}
 
+		principal := principalFromContext(c)
+		if !scriptAllowed(appConfig, filename, principal) {
+			c.String(http.StatusForbidden, "Not permitted to run this script")
+			return
+		}
+
 		// Parse JSON from request body
 		var jsonData map[string]interface{}
 		err := c.ShouldBindJSON(&jsonData)
@@ -290,20 +321,53 @@ func main() {
 		// Generate a unique ID for this script execution
 		id := uuid.New().String()
 
-		// Create a new ScriptStatus
-		scriptStatuses[id] = &ScriptStatus{
-			Finished: false,
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout(c))
+
+		job := &Job{
+			ID:        id,
+			Filename:  filename,
+			Payload:   jsonData,
+			StartedAt: time.Now(),
+			Status:    JobStatusRunning,
 		}
+		if err := appJobStore.Put(job); err != nil {
+			cancel()
+			c.String(http.StatusInternalServerError, "failed to create job: "+err.Error())
+			return
+		}
+
+		runningCancels.Lock()
+		runningCancels.m[id] = cancel
+		runningCancels.Unlock()
 
 		// Start a goroutine to run the script
 		go func() {
-			result, err := runLuaScript(filename, jsonData)
-			// Update the status when the script is done
-			scriptStatuses[id].Finished = true
-			scriptStatuses[id].Result = result
+			defer cancel()
+			defer func() {
+				runningCancels.Lock()
+				delete(runningCancels.m, id)
+				runningCancels.Unlock()
+			}()
+
+			result, err := runLuaScript(ctx, id, filename, jsonData, principal)
+
+			// Build a fresh Job for the terminal update rather than mutating
+			// the one already passed to Put above: the store may be handing
+			// that same value to concurrent GET /status/:id or GET /jobs
+			// callers right now.
+			finished := *job
+			finished.FinishedAt = time.Now()
+			finished.Result = result
+			finished.Status = JobStatusFinished
 			if err != nil {
-				scriptStatuses[id].Error = err
+				finished.Error = err.Error()
+				finished.Status = JobStatusError
+				if ctx.Err() == context.Canceled {
+					finished.Status = JobStatusCancelled
+				}
 			}
+			appJobStore.Put(&finished)
+			appEvents.Publish(id, JobEvent{Type: "status", Status: finished.Status})
 		}()
 
 		// Return the ID to the client
@@ -320,6 +384,12 @@ func main() {
 			return
 		}
 
+		principal := principalFromContext(c)
+		if !scriptAllowed(appConfig, filename, principal) {
+			c.String(http.StatusForbidden, "Not permitted to run this script")
+			return
+		}
+
 		// Parse JSON from request body
 		var jsonData map[string]interface{}
 		err := c.ShouldBindJSON(&jsonData)
@@ -329,13 +399,19 @@ func main() {
 		}
 
 		// Run the Lua script
-		result, err := runLuaScript(filename, jsonData)
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout(c))
+		defer cancel()
+
+		result, err := runLuaScript(ctx, "", filename, jsonData, principal)
 		if err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
 
-		c.String(http.StatusOK, result)
+		// result is already a JSON document (see runLuaScript); send it as
+		// one rather than text/plain so clients that trust Content-Type
+		// parse it correctly.
+		c.Data(http.StatusOK, "application/json", []byte(result))
 	})
 
 	router.Run() // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// toLValue converts a Go value decoded from JSON (string, float64, bool, nil,
+// []interface{}, map[string]interface{}) into the equivalent Lua value.
+// Nested arrays and objects are converted recursively; arrays become
+// 1-indexed Lua tables and objects become string-keyed Lua tables.
+func toLValue(L *lua.LState, v interface{}) lua.LValue {
+	switch val := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(val)
+	case float64:
+		return lua.LNumber(val)
+	case string:
+		return lua.LString(val)
+	case []interface{}:
+		tbl := L.CreateTable(len(val), 0)
+		for i, item := range val {
+			tbl.RawSetInt(i+1, toLValue(L, item))
+		}
+		return tbl
+	case map[string]interface{}:
+		tbl := L.CreateTable(0, len(val))
+		for k, item := range val {
+			tbl.RawSetString(k, toLValue(L, item))
+		}
+		return tbl
+	default:
+		return lua.LString(fmt.Sprint(val))
+	}
+}
+
+// fromLValue converts a Lua value back into a plain Go value suitable for
+// json.Marshal: LNil -> nil, LBool -> bool, LNumber -> float64,
+// LString -> string, and *LTable -> either []interface{} (if the table is
+// array-like: contiguous integer keys starting at 1) or map[string]interface{}.
+func fromLValue(v lua.LValue) interface{} {
+	switch val := v.(type) {
+	case *lua.LNilType:
+		return nil
+	case lua.LBool:
+		return bool(val)
+	case lua.LNumber:
+		return float64(val)
+	case lua.LString:
+		return string(val)
+	case *lua.LTable:
+		return tableToGo(val)
+	default:
+		return val.String()
+	}
+}
+
+// tableToGo converts a Lua table to either a []interface{} or a
+// map[string]interface{}, depending on whether its keys form a contiguous
+// array starting at 1.
+func tableToGo(tbl *lua.LTable) interface{} {
+	length := tbl.Len()
+
+	if length > 0 && isArrayLike(tbl, length) {
+		arr := make([]interface{}, length)
+		for i := 1; i <= length; i++ {
+			arr[i-1] = fromLValue(tbl.RawGetInt(i))
+		}
+		return arr
+	}
+
+	m := make(map[string]interface{})
+	tbl.ForEach(func(k lua.LValue, v lua.LValue) {
+		m[k.String()] = fromLValue(v)
+	})
+	return m
+}
+
+// isArrayLike reports whether tbl's only keys are the contiguous integers
+// 1..length, i.e. it was built as a Lua array rather than a hash.
+func isArrayLike(tbl *lua.LTable, length int) bool {
+	count := 0
+	ok := true
+	tbl.ForEach(func(k lua.LValue, _ lua.LValue) {
+		n, isNum := k.(lua.LNumber)
+		if !isNum || int(n) < 1 || int(n) > length || float64(int(n)) != float64(n) {
+			ok = false
+		}
+		count++
+	})
+	return ok && count == length
+}
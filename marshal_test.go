@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestToLValueFromLValueRoundTrip(t *testing.T) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	cases := []interface{}{
+		nil,
+		true,
+		false,
+		float64(42),
+		"hello",
+		[]interface{}{float64(1), float64(2), float64(3)},
+		map[string]interface{}{"a": float64(1), "b": "two"},
+		map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "x"},
+				map[string]interface{}{"name": "y"},
+			},
+			"ok": true,
+		},
+	}
+
+	for _, want := range cases {
+		got := fromLValue(toLValue(L, want))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip mismatch: want %#v, got %#v", want, got)
+		}
+	}
+}
+
+func TestTableToGoArrayVsObject(t *testing.T) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	array := L.CreateTable(3, 0)
+	array.RawSetInt(1, lua.LString("a"))
+	array.RawSetInt(2, lua.LString("b"))
+	array.RawSetInt(3, lua.LString("c"))
+
+	got := tableToGo(array)
+	want := []interface{}{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected array-like table to become %#v, got %#v", want, got)
+	}
+
+	object := L.CreateTable(0, 1)
+	object.RawSetString("name", lua.LString("widget"))
+
+	got = tableToGo(object)
+	wantMap := map[string]interface{}{"name": "widget"}
+	if !reflect.DeepEqual(got, wantMap) {
+		t.Errorf("expected string-keyed table to become %#v, got %#v", wantMap, got)
+	}
+}
+
+func TestTableToGoNonContiguousKeysIsNotArray(t *testing.T) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	tbl := L.CreateTable(0, 0)
+	tbl.RawSetInt(1, lua.LString("a"))
+	tbl.RawSetInt(3, lua.LString("c"))
+
+	got := tableToGo(tbl)
+	if _, isArray := got.([]interface{}); isArray {
+		t.Errorf("table with a gap at index 2 should not be treated as array-like, got %#v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// cachedScript is a script pre-compiled into a *lua.FunctionProto, so each
+// request only needs to push and call it rather than re-parsing the source.
+type cachedScript struct {
+	Content string
+	ModTime time.Time
+	SHA256  string
+	Proto   *lua.FunctionProto
+}
+
+// ScriptCache compiles .lua files under dir on first use and evicts an
+// entry as soon as fsnotify reports the underlying file changed, so editing
+// a script on disk takes effect on the next request without a restart.
+type ScriptCache struct {
+	mu      sync.RWMutex
+	dir     string
+	entries map[string]*cachedScript
+	watcher *fsnotify.Watcher
+}
+
+// newScriptCache starts watching dir for changes and returns an empty cache;
+// scripts are compiled lazily by Load.
+func newScriptCache(dir string) (*ScriptCache, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	sc := &ScriptCache{
+		dir:     dir,
+		entries: make(map[string]*cachedScript),
+		watcher: watcher,
+	}
+	go sc.watch()
+	return sc, nil
+}
+
+func (sc *ScriptCache) watch() {
+	for {
+		select {
+		case event, ok := <-sc.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Rename|fsnotify.Remove) != 0 {
+				sc.evict(filepath.Base(event.Name))
+			}
+		case err, ok := <-sc.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("script cache watcher error: %v", err)
+		}
+	}
+}
+
+func (sc *ScriptCache) evict(filename string) {
+	sc.mu.Lock()
+	delete(sc.entries, filename)
+	sc.mu.Unlock()
+}
+
+// Load returns the compiled entry for filename, compiling and caching it on
+// first use (or after an fsnotify event / Reload invalidates it).
+func (sc *ScriptCache) Load(filename string) (*cachedScript, error) {
+	sc.mu.RLock()
+	entry, ok := sc.entries[filename]
+	sc.mu.RUnlock()
+	if ok {
+		return entry, nil
+	}
+
+	path := filepath.Join(sc.dir, filename)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(string(data)), filename)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := lua.Compile(chunk, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	entry = &cachedScript{
+		Content: string(data),
+		ModTime: info.ModTime(),
+		SHA256:  hex.EncodeToString(sum[:]),
+		Proto:   proto,
+	}
+
+	sc.mu.Lock()
+	sc.entries[filename] = entry
+	sc.mu.Unlock()
+
+	return entry, nil
+}
+
+// Reload drops every cached entry, forcing a recompile on next Load. Used
+// by POST /admin/reload.
+func (sc *ScriptCache) Reload() {
+	sc.mu.Lock()
+	sc.entries = make(map[string]*cachedScript)
+	sc.mu.Unlock()
+}
+
+// List returns filename -> sha256 for every currently cached script, for
+// GET /admin/scripts.
+func (sc *ScriptCache) List() map[string]string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	hashes := make(map[string]string, len(sc.entries))
+	for name, entry := range sc.entries {
+		hashes[name] = entry.SHA256
+	}
+	return hashes
+}